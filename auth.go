@@ -0,0 +1,307 @@
+package gomcbot
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// AuthProvider abstracts how a bot obtains the identity it presents during
+// login and proves that identity to the session server. loginAuth uses
+// whichever AuthProvider the bot is configured with instead of always
+// talking to Mojang's legacy yggdrasil endpoints.
+type AuthProvider interface {
+	// Authenticate logs the account in and returns the access token, UUID
+	// and username to present in LoginStart and JoinServer.
+	Authenticate(ctx context.Context) (accessToken, uuid, name string, err error)
+
+	// JoinServer tells the auth backend that the account is joining the
+	// server identified by serverID, so it can answer Mojang's hasJoined
+	// check. Offline providers may make this a no-op.
+	JoinServer(ctx context.Context, serverID string, sharedSecret, publicKey []byte) error
+}
+
+// loginAuth authenticates with provider and notifies the session server
+// that the account is joining, returning the name and UUID to use for the
+// rest of the login.
+func loginAuth(ctx context.Context, provider AuthProvider, sharedSecret []byte, er encryptionRequest) (name, uuid string, err error) {
+	_, uuid, name, err = provider.Authenticate(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("authenticate fail: %v", err)
+	}
+	if err := provider.JoinServer(ctx, er.ServerID, sharedSecret, er.PublicKey); err != nil {
+		return "", "", err
+	}
+	return name, uuid, nil
+}
+
+type mcProfile struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type joinRequest struct {
+	AccessToken     string    `json:"accessToken"`
+	SelectedProfile mcProfile `json:"selectedProfile"`
+	ServerID        string    `json:"serverId"`
+}
+
+// joinSessionServer POSTs to sessionServerURL/session/minecraft/join, the
+// call every auth backend makes (with the same request shape) to let
+// Mojang's hasJoined check succeed for the account identified by
+// accessToken/uuid/name.
+func joinSessionServer(ctx context.Context, sessionServerURL, accessToken, uuid, name, serverID string, sharedSecret, publicKey []byte) (err error) {
+	digest := AuthDigest(serverID, sharedSecret, publicKey)
+
+	if OnSessionJoin != nil {
+		start := time.Now()
+		defer func() { OnSessionJoin(serverID, time.Since(start), err) }()
+	}
+
+	body, err := json.Marshal(joinRequest{
+		AccessToken: accessToken,
+		SelectedProfile: mcProfile{
+			ID:   uuid,
+			Name: name,
+		},
+		ServerID: digest,
+	})
+	if err != nil {
+		return fmt.Errorf("create request packet to authenticate faile: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sessionServerURL+"/session/minecraft/join",
+		bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("make request error: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gomcbot")
+	req.Header.Set("Connection", "keep-alive")
+
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("post fail: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.Status != "204 No Content" {
+		return fmt.Errorf("auth fail: %s", string(respBody))
+	}
+	return nil
+}
+
+// YggdrasilAuth is the classic Mojang login flow: either an AccessToken
+// obtained elsewhere is used directly, or Email/Password are exchanged for
+// one via the configured AuthServer's legacy authserver.
+type YggdrasilAuth struct {
+	AccessToken string
+	ClientToken string
+	UUID        string
+	Name        string
+
+	Email    string
+	Password string
+
+	// Server is the yggdrasil backend to authenticate against. The zero
+	// value falls back to MojangAuthServer, so existing callers that never
+	// set it keep talking to Mojang.
+	Server AuthServer
+
+	// CredentialPath, if set, caches the access token on disk (encrypted,
+	// see CredentialPassphrase) so restarting the bot doesn't require
+	// logging in again. CredentialPassphrase may be empty to fall back to
+	// a key file alongside CredentialPath; see Load/Save.
+	CredentialPath       string
+	CredentialPassphrase string
+}
+
+func (a *YggdrasilAuth) server() AuthServer {
+	if a.Server.AuthServerURL == "" && a.Server.SessionServerURL == "" {
+		return MojangAuthServer
+	}
+	return a.Server
+}
+
+func (a *YggdrasilAuth) Authenticate(ctx context.Context) (accessToken, uuid, name string, err error) {
+	if a.AccessToken != "" {
+		return a.AccessToken, a.UUID, a.Name, nil
+	}
+
+	if a.CredentialPath != "" {
+		if ok := a.loadCachedCredentials(ctx); ok {
+			return a.AccessToken, a.UUID, a.Name, nil
+		}
+	}
+
+	if a.ClientToken == "" {
+		a.ClientToken = newClientToken()
+	}
+
+	body, err := json.Marshal(struct {
+		Agent struct {
+			Name    string `json:"name"`
+			Version int    `json:"version"`
+		} `json:"agent"`
+		Username    string `json:"username"`
+		Password    string `json:"password"`
+		ClientToken string `json:"clientToken"`
+	}{
+		Agent: struct {
+			Name    string `json:"name"`
+			Version int    `json:"version"`
+		}{Name: "Minecraft", Version: 1},
+		Username:    a.Email,
+		Password:    a.Password,
+		ClientToken: a.ClientToken,
+	})
+	if err != nil {
+		return "", "", "", fmt.Errorf("create authenticate request fail: %v", err)
+	}
+
+	server := a.server()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.AuthServerURL+"/authenticate",
+		bytes.NewReader(body))
+	if err != nil {
+		return "", "", "", fmt.Errorf("make request error: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if server.UserAgent != "" {
+		req.Header.Set("User-Agent", server.UserAgent)
+	}
+
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return "", "", "", fmt.Errorf("authenticate request fail: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", "", "", fmt.Errorf("read authenticate response fail: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", "", "", fmt.Errorf("authenticate fail: %s", string(respBody))
+	}
+
+	var result struct {
+		AccessToken     string    `json:"accessToken"`
+		ClientToken     string    `json:"clientToken"`
+		SelectedProfile mcProfile `json:"selectedProfile"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", "", "", fmt.Errorf("decode authenticate response fail: %v", err)
+	}
+
+	a.AccessToken, a.ClientToken, a.UUID, a.Name = result.AccessToken, result.ClientToken, result.SelectedProfile.ID, result.SelectedProfile.Name
+	a.saveCachedCredentials()
+	return a.AccessToken, a.UUID, a.Name, nil
+}
+
+func (a *YggdrasilAuth) JoinServer(ctx context.Context, serverID string, sharedSecret, publicKey []byte) error {
+	return joinSessionServer(ctx, a.server().SessionServerURL, a.AccessToken, a.UUID, a.Name, serverID, sharedSecret, publicKey)
+}
+
+// loadCachedCredentials tries to bring a.AccessToken/UUID/Name up from the
+// on-disk cache at a.CredentialPath, validating it against the auth server
+// and transparently refreshing it if it has expired. It reports whether it
+// left a usable, validated token in a.
+func (a *YggdrasilAuth) loadCachedCredentials(ctx context.Context) bool {
+	creds, err := Load(a.CredentialPath, a.CredentialPassphrase)
+	if err != nil {
+		return false
+	}
+
+	if a.validateToken(ctx, creds.AccessToken, creds.ClientToken) {
+		a.AccessToken, a.ClientToken, a.UUID, a.Name = creds.AccessToken, creds.ClientToken, creds.UUID, creds.Name
+		return true
+	}
+
+	newToken, uuid, name, err := a.refreshToken(ctx, creds.AccessToken, creds.ClientToken)
+	if err != nil {
+		return false
+	}
+	a.AccessToken, a.ClientToken, a.UUID, a.Name = newToken, creds.ClientToken, uuid, name
+	a.saveCachedCredentials()
+	return true
+}
+
+func (a *YggdrasilAuth) saveCachedCredentials() {
+	if a.CredentialPath == "" {
+		return
+	}
+	_ = Save(a.CredentialPath, &Credentials{
+		AccessToken: a.AccessToken,
+		ClientToken: a.ClientToken,
+		UUID:        a.UUID,
+		Name:        a.Name,
+	}, a.CredentialPassphrase)
+}
+
+// validateToken calls the auth server's /validate endpoint, which answers
+// with 204 No Content for a still-usable access token.
+func (a *YggdrasilAuth) validateToken(ctx context.Context, accessToken, clientToken string) bool {
+	body, err := json.Marshal(struct {
+		AccessToken string `json:"accessToken"`
+		ClientToken string `json:"clientToken"`
+	}{accessToken, clientToken})
+	if err != nil {
+		return false
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.server().AuthServerURL+"/validate", bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusNoContent
+}
+
+// refreshToken calls the auth server's /refresh endpoint to exchange an
+// expired access token for a new one without asking the user to log in
+// again.
+func (a *YggdrasilAuth) refreshToken(ctx context.Context, accessToken, clientToken string) (newAccessToken, uuid, name string, err error) {
+	body, err := json.Marshal(struct {
+		AccessToken string `json:"accessToken"`
+		ClientToken string `json:"clientToken"`
+	}{accessToken, clientToken})
+	if err != nil {
+		return "", "", "", fmt.Errorf("create refresh request fail: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.server().AuthServerURL+"/refresh", bytes.NewReader(body))
+	if err != nil {
+		return "", "", "", fmt.Errorf("make request error: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var result struct {
+		AccessToken     string    `json:"accessToken"`
+		SelectedProfile mcProfile `json:"selectedProfile"`
+	}
+	if err := doJSON(req, &result); err != nil {
+		return "", "", "", fmt.Errorf("refresh token fail: %v", err)
+	}
+	return result.AccessToken, result.SelectedProfile.ID, result.SelectedProfile.Name, nil
+}
+
+// newClientToken generates a random identifier to tie together a session's
+// access, validate and refresh calls, the way launchers generate one once
+// per install.
+func newClientToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
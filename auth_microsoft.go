@@ -0,0 +1,264 @@
+package gomcbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// MicrosoftAuth logs in through Microsoft/Xbox Live OAuth: a Microsoft
+// refresh token is exchanged for an XBL user token, then an XSTS token,
+// then a Minecraft bearer token, following the chain documented at
+// https://wiki.vg/Microsoft_Authentication_Scheme
+type MicrosoftAuth struct {
+	ClientID     string
+	RefreshToken string
+
+	// CredentialPath, if set, persists RefreshToken and the resolved
+	// Minecraft profile between runs, the same way YggdrasilAuth does.
+	// Microsoft rotates RefreshToken on every use, so without this the
+	// token a long-running bot farm was configured with goes stale after
+	// its first restart; Authenticate loads the latest cached one before
+	// logging in and saves the new one it gets back afterwards.
+	// CredentialPassphrase may be empty to fall back to a key file
+	// alongside CredentialPath; see Load/Save.
+	CredentialPath       string
+	CredentialPassphrase string
+
+	accessToken string // Minecraft bearer token, filled in by Authenticate
+	uuid, name  string
+}
+
+func (a *MicrosoftAuth) Authenticate(ctx context.Context) (accessToken, uuid, name string, err error) {
+	if a.CredentialPath != "" {
+		if creds, err := Load(a.CredentialPath, a.CredentialPassphrase); err == nil && creds.ClientToken != "" {
+			a.RefreshToken = creds.ClientToken
+		}
+	}
+
+	msToken, err := a.refreshMicrosoftToken(ctx)
+	if err != nil {
+		return "", "", "", fmt.Errorf("refresh microsoft token fail: %v", err)
+	}
+	xblToken, uhs, err := a.authenticateXBL(ctx, msToken)
+	if err != nil {
+		return "", "", "", fmt.Errorf("authenticate xbox live fail: %v", err)
+	}
+	xstsToken, err := a.authenticateXSTS(ctx, xblToken)
+	if err != nil {
+		return "", "", "", fmt.Errorf("authenticate xsts fail: %v", err)
+	}
+	mcToken, err := a.loginWithXbox(ctx, uhs, xstsToken)
+	if err != nil {
+		return "", "", "", fmt.Errorf("login with xbox fail: %v", err)
+	}
+	uuid, name, err = a.minecraftProfile(ctx, mcToken)
+	if err != nil {
+		return "", "", "", fmt.Errorf("fetch minecraft profile fail: %v", err)
+	}
+
+	a.accessToken, a.uuid, a.name = mcToken, uuid, name
+	a.saveCachedCredentials()
+	return mcToken, uuid, name, nil
+}
+
+func (a *MicrosoftAuth) JoinServer(ctx context.Context, serverID string, sharedSecret, publicKey []byte) error {
+	return joinSessionServer(ctx, MojangAuthServer.SessionServerURL, a.accessToken, a.uuid, a.name, serverID, sharedSecret, publicKey)
+}
+
+func (a *MicrosoftAuth) saveCachedCredentials() {
+	if a.CredentialPath == "" {
+		return
+	}
+	_ = Save(a.CredentialPath, &Credentials{
+		AccessToken: a.accessToken,
+		ClientToken: a.RefreshToken,
+		UUID:        a.uuid,
+		Name:        a.name,
+	}, a.CredentialPassphrase)
+}
+
+func (a *MicrosoftAuth) refreshMicrosoftToken(ctx context.Context) (string, error) {
+	form := url.Values{
+		"client_id":     {a.ClientID},
+		"refresh_token": {a.RefreshToken},
+		"grant_type":    {"refresh_token"},
+		"scope":         {"XboxLive.signin offline_access"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://login.live.com/oauth20_token.srf",
+		bytes.NewReader([]byte(form.Encode())))
+	if err != nil {
+		return "", fmt.Errorf("make request error: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var result struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := doJSON(req, &result); err != nil {
+		return "", err
+	}
+	a.RefreshToken = result.RefreshToken
+	return result.AccessToken, nil
+}
+
+func (a *MicrosoftAuth) authenticateXBL(ctx context.Context, msAccessToken string) (token, uhs string, err error) {
+	body, err := json.Marshal(struct {
+		Properties struct {
+			AuthMethod string `json:"AuthMethod"`
+			SiteName   string `json:"SiteName"`
+			RpsTicket  string `json:"RpsTicket"`
+		} `json:"Properties"`
+		RelyingParty string `json:"RelyingParty"`
+		TokenType    string `json:"TokenType"`
+	}{
+		Properties: struct {
+			AuthMethod string `json:"AuthMethod"`
+			SiteName   string `json:"SiteName"`
+			RpsTicket  string `json:"RpsTicket"`
+		}{
+			AuthMethod: "RPS",
+			SiteName:   "user.auth.xboxlive.com",
+			RpsTicket:  "d=" + msAccessToken,
+		},
+		RelyingParty: "http://auth.xboxlive.com",
+		TokenType:    "JWT",
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("create request packet fail: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://user.auth.xboxlive.com/user/authenticate",
+		bytes.NewReader(body))
+	if err != nil {
+		return "", "", fmt.Errorf("make request error: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	var result xblTokenResponse
+	if err := doJSON(req, &result); err != nil {
+		return "", "", err
+	}
+	return result.Token, result.uhs(), nil
+}
+
+func (a *MicrosoftAuth) authenticateXSTS(ctx context.Context, xblToken string) (string, error) {
+	body, err := json.Marshal(struct {
+		Properties struct {
+			SandboxID  string   `json:"SandboxId"`
+			UserTokens []string `json:"UserTokens"`
+		} `json:"Properties"`
+		RelyingParty string `json:"RelyingParty"`
+		TokenType    string `json:"TokenType"`
+	}{
+		Properties: struct {
+			SandboxID  string   `json:"SandboxId"`
+			UserTokens []string `json:"UserTokens"`
+		}{
+			SandboxID:  "RETAIL",
+			UserTokens: []string{xblToken},
+		},
+		RelyingParty: "rp://api.minecraftservices.com/",
+		TokenType:    "JWT",
+	})
+	if err != nil {
+		return "", fmt.Errorf("create request packet fail: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://xsts.auth.xboxlive.com/xsts/authorize",
+		bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("make request error: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	var result xblTokenResponse
+	if err := doJSON(req, &result); err != nil {
+		return "", err
+	}
+	return result.Token, nil
+}
+
+type xblTokenResponse struct {
+	Token         string `json:"Token"`
+	DisplayClaims struct {
+		Xui []struct {
+			Uhs string `json:"uhs"`
+		} `json:"xui"`
+	} `json:"DisplayClaims"`
+}
+
+func (r xblTokenResponse) uhs() string {
+	if len(r.DisplayClaims.Xui) == 0 {
+		return ""
+	}
+	return r.DisplayClaims.Xui[0].Uhs
+}
+
+func (a *MicrosoftAuth) loginWithXbox(ctx context.Context, uhs, xstsToken string) (string, error) {
+	body, err := json.Marshal(struct {
+		IdentityToken string `json:"identityToken"`
+	}{
+		IdentityToken: fmt.Sprintf("XBL3.0 x=%s;%s", uhs, xstsToken),
+	})
+	if err != nil {
+		return "", fmt.Errorf("create request packet fail: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.minecraftservices.com/authentication/login_with_xbox",
+		bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("make request error: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := doJSON(req, &result); err != nil {
+		return "", err
+	}
+	return result.AccessToken, nil
+}
+
+func (a *MicrosoftAuth) minecraftProfile(ctx context.Context, mcAccessToken string) (uuid, name string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.minecraftservices.com/minecraft/profile", nil)
+	if err != nil {
+		return "", "", fmt.Errorf("make request error: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+mcAccessToken)
+
+	var result mcProfile
+	if err := doJSON(req, &result); err != nil {
+		return "", "", err
+	}
+	return result.ID, result.Name, nil
+}
+
+// doJSON performs req and decodes a 200 OK JSON response into out.
+func doJSON(req *http.Request, out interface{}) error {
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("post fail: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response fail: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request fail: %s", string(body))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decode response fail: %v", err)
+	}
+	return nil
+}
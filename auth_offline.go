@@ -0,0 +1,33 @@
+package gomcbot
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+)
+
+// OfflineAuth logs in without contacting Mojang at all, for cracked/offline
+// servers. It skips genEncryptionKeyResponse upstream (the bot never enables
+// encryption) and derives a deterministic UUID from the username the same
+// way vanilla offline-mode servers do.
+type OfflineAuth struct {
+	Name string
+}
+
+func (a *OfflineAuth) Authenticate(ctx context.Context) (accessToken, uuid, name string, err error) {
+	return "", offlineUUID(a.Name), a.Name, nil
+}
+
+// JoinServer is a no-op: offline mode never talks to the session server.
+func (a *OfflineAuth) JoinServer(ctx context.Context, serverID string, sharedSecret, publicKey []byte) error {
+	return nil
+}
+
+// offlineUUID derives a version-3 UUID from "OfflinePlayer:<name>", matching
+// vanilla's offline-mode player UUIDs.
+func offlineUUID(name string) string {
+	sum := md5.Sum([]byte("OfflinePlayer:" + name))
+	sum[6] = (sum[6] & 0x0f) | 0x30 // version 3
+	sum[8] = (sum[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
@@ -0,0 +1,11 @@
+package gomcbot
+
+import "testing"
+
+func TestOfflineUUID(t *testing.T) {
+	got := offlineUUID("Steve")
+	want := "5627dd98-e6be-3c21-b8a8-e92344183641"
+	if got != want {
+		t.Fatalf("offlineUUID(%q) = %q, want %q", "Steve", got, want)
+	}
+}
@@ -0,0 +1,113 @@
+package gomcbot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AuthServer describes a yggdrasil-compatible auth backend: Mojang itself,
+// or a third-party Authlib-injector deployment such as Ely.by, LittleSkin,
+// or a private server's own injector root. It lets YggdrasilAuth log into
+// networks the hardcoded Mojang URLs can't reach. The vanilla handshake has
+// no dedicated field for an injector root, so callers either set
+// YggdrasilAuth.Server themselves (optionally built with DiscoverAuthServer)
+// or call ResolveAuthServer on the server address to pick up the
+// "host?root=<url>" hint some injector-aware launchers smuggle in.
+type AuthServer struct {
+	// AuthServerURL is the base URL /authenticate is posted to, e.g.
+	// "https://authserver.mojang.com".
+	AuthServerURL string
+	// SessionServerURL is the base URL /session/minecraft/join is posted
+	// to, e.g. "https://sessionserver.mojang.com".
+	SessionServerURL string
+	// UserAgent, if set, is sent with YggdrasilAuth's /authenticate
+	// request. Some injector deployments key their error messages off of
+	// it. The /validate and /refresh requests send no User-Agent, and the
+	// session join request always sends "gomcbot".
+	UserAgent string
+}
+
+// MojangAuthServer is the default AuthServer, pointing at Mojang's own
+// yggdrasil endpoints.
+var MojangAuthServer = AuthServer{
+	AuthServerURL:    "https://authserver.mojang.com",
+	SessionServerURL: "https://sessionserver.mojang.com",
+	UserAgent:        "gomcbot",
+}
+
+// DiscoverAuthServer fetches an Authlib-injector root's api/metadata
+// response and builds the AuthServer it advertises, for callers to assign
+// to YggdrasilAuth.Server. rootURL is the injector's base URL, e.g.
+// "https://authserver.ely.by".
+func DiscoverAuthServer(ctx context.Context, rootURL string) (AuthServer, error) {
+	rootURL = strings.TrimRight(rootURL, "/")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rootURL, nil)
+	if err != nil {
+		return AuthServer{}, fmt.Errorf("make request error: %v", err)
+	}
+
+	var meta struct {
+		Meta struct {
+			ServerName         string `json:"serverName"`
+			ImplementationName string `json:"implementationName"`
+		} `json:"meta"`
+	}
+	if err := doJSON(req, &meta); err != nil {
+		return AuthServer{}, fmt.Errorf("fetch injector metadata fail: %v", err)
+	}
+
+	name := meta.Meta.ServerName
+	if name == "" {
+		name = meta.Meta.ImplementationName
+	}
+	return AuthServer{
+		AuthServerURL:    rootURL + "/authserver",
+		SessionServerURL: rootURL + "/sessionserver",
+		UserAgent:        fmt.Sprintf("gomcbot/authlib-injector (%s)", name),
+	}, nil
+}
+
+// ParseInjectorHint extracts an Authlib-injector root URL that some
+// injector-aware launchers smuggle through the server address field as
+// "host?root=<url>", since the handshake has no dedicated field for it. ok
+// is false if serverAddress carries no such hint, in which case host is
+// serverAddress unchanged.
+func ParseInjectorHint(serverAddress string) (host, injectorRootURL string, ok bool) {
+	parts := strings.SplitN(serverAddress, "?", 2)
+	if len(parts) != 2 {
+		return serverAddress, "", false
+	}
+
+	values, err := url.ParseQuery(parts[1])
+	if err != nil {
+		return serverAddress, "", false
+	}
+	root := values.Get("root")
+	if root == "" {
+		return serverAddress, "", false
+	}
+	return parts[0], root, true
+}
+
+// ResolveAuthServer splits an injector hint off serverAddress with
+// ParseInjectorHint and, if one was found, discovers the AuthServer it
+// points at with DiscoverAuthServer. It's the single entry point
+// connect-time code should call before dialing: the returned host is what
+// to dial, and server is what to assign to YggdrasilAuth.Server. If
+// serverAddress carries no hint, host is serverAddress unchanged and server
+// is MojangAuthServer.
+func ResolveAuthServer(ctx context.Context, serverAddress string) (host string, server AuthServer, err error) {
+	host, root, ok := ParseInjectorHint(serverAddress)
+	if !ok {
+		return host, MojangAuthServer, nil
+	}
+	server, err = DiscoverAuthServer(ctx, root)
+	if err != nil {
+		return host, AuthServer{}, err
+	}
+	return host, server, nil
+}
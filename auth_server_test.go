@@ -0,0 +1,88 @@
+package gomcbot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiscoverAuthServer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"meta":{"serverName":"ely.by"}}`))
+	}))
+	defer srv.Close()
+
+	got, err := DiscoverAuthServer(context.Background(), srv.URL+"/")
+	if err != nil {
+		t.Fatalf("DiscoverAuthServer() error = %v", err)
+	}
+
+	want := AuthServer{
+		AuthServerURL:    srv.URL + "/authserver",
+		SessionServerURL: srv.URL + "/sessionserver",
+		UserAgent:        "gomcbot/authlib-injector (ely.by)",
+	}
+	if got != want {
+		t.Fatalf("DiscoverAuthServer() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseInjectorHint(t *testing.T) {
+	host, root, ok := ParseInjectorHint("mc.example.com?root=https://authserver.ely.by")
+	if !ok {
+		t.Fatal("ParseInjectorHint() ok = false, want true")
+	}
+	if host != "mc.example.com" || root != "https://authserver.ely.by" {
+		t.Fatalf("ParseInjectorHint() = (%q, %q), want (%q, %q)", host, root, "mc.example.com", "https://authserver.ely.by")
+	}
+}
+
+func TestParseInjectorHintNoHint(t *testing.T) {
+	host, _, ok := ParseInjectorHint("mc.example.com")
+	if ok {
+		t.Fatal("ParseInjectorHint() ok = true, want false")
+	}
+	if host != "mc.example.com" {
+		t.Fatalf("ParseInjectorHint() host = %q, want %q", host, "mc.example.com")
+	}
+}
+
+func TestResolveAuthServerWithHint(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"meta":{"serverName":"ely.by"}}`))
+	}))
+	defer srv.Close()
+
+	host, got, err := ResolveAuthServer(context.Background(), "mc.example.com?root="+srv.URL+"/")
+	if err != nil {
+		t.Fatalf("ResolveAuthServer() error = %v", err)
+	}
+	if host != "mc.example.com" {
+		t.Fatalf("ResolveAuthServer() host = %q, want %q", host, "mc.example.com")
+	}
+
+	want := AuthServer{
+		AuthServerURL:    srv.URL + "/authserver",
+		SessionServerURL: srv.URL + "/sessionserver",
+		UserAgent:        "gomcbot/authlib-injector (ely.by)",
+	}
+	if got != want {
+		t.Fatalf("ResolveAuthServer() server = %+v, want %+v", got, want)
+	}
+}
+
+func TestResolveAuthServerNoHint(t *testing.T) {
+	host, got, err := ResolveAuthServer(context.Background(), "mc.example.com")
+	if err != nil {
+		t.Fatalf("ResolveAuthServer() error = %v", err)
+	}
+	if host != "mc.example.com" {
+		t.Fatalf("ResolveAuthServer() host = %q, want %q", host, "mc.example.com")
+	}
+	if got != MojangAuthServer {
+		t.Fatalf("ResolveAuthServer() server = %+v, want %+v", got, MojangAuthServer)
+	}
+}
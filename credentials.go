@@ -0,0 +1,139 @@
+package gomcbot
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Credentials is the subset of a logged-in account's state worth persisting
+// between bot runs, so a long-running bot farm doesn't need a human to
+// re-enter Mojang/Microsoft credentials on every restart. ClientToken holds
+// whatever secondary token the provider needs alongside AccessToken to log
+// back in unattended: YggdrasilAuth stores its yggdrasil client token there,
+// MicrosoftAuth its rotating OAuth refresh token.
+type Credentials struct {
+	AccessToken string
+	ClientToken string
+	UUID        string
+	Name        string
+}
+
+const (
+	credentialSaltSize  = 16
+	credentialNonceSize = 12
+	credentialKeySize   = 32 // AES-256
+	pbkdf2Iterations    = 100000
+)
+
+// Load reads and decrypts the credentials stored at path by Save. If
+// passphrase is empty, the key is read from the key file Save wrote
+// alongside path instead of being derived from a passphrase.
+func Load(path, passphrase string) (*Credentials, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read credential file fail: %v", err)
+	}
+	if len(raw) < credentialSaltSize+credentialNonceSize {
+		return nil, fmt.Errorf("credential file truncated")
+	}
+	salt := raw[:credentialSaltSize]
+	nonce := raw[credentialSaltSize : credentialSaltSize+credentialNonceSize]
+	ciphertext := raw[credentialSaltSize+credentialNonceSize:]
+
+	gcm, err := credentialGCM(path, passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt credential file fail: %v", err)
+	}
+
+	var creds Credentials
+	if err := json.Unmarshal(plaintext, &creds); err != nil {
+		return nil, fmt.Errorf("decode credentials fail: %v", err)
+	}
+	return &creds, nil
+}
+
+// Save encrypts creds with AES-256-GCM and writes it to path, prepending a
+// random salt and nonce so Load can recover the key and decrypt.
+func Save(path string, creds *Credentials, passphrase string) error {
+	salt := make([]byte, credentialSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("generate salt fail: %v", err)
+	}
+	gcm, err := credentialGCM(path, passphrase, salt)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return fmt.Errorf("encode credentials fail: %v", err)
+	}
+
+	nonce := make([]byte, credentialNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("generate nonce fail: %v", err)
+	}
+	ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
+
+	out := make([]byte, 0, len(salt)+len(nonce)+len(ciphertext))
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	out = append(out, ciphertext...)
+	if err := ioutil.WriteFile(path, out, 0600); err != nil {
+		return fmt.Errorf("write credential file fail: %v", err)
+	}
+	return nil
+}
+
+func credentialGCM(path, passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := credentialKey(path, passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher fail: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("create gcm fail: %v", err)
+	}
+	return gcm, nil
+}
+
+// credentialKey derives the AES-256 key either from passphrase via
+// PBKDF2-SHA256, or from a random key file kept alongside path when no
+// passphrase is given.
+func credentialKey(path, passphrase string, salt []byte) ([]byte, error) {
+	if passphrase != "" {
+		return pbkdf2.Key([]byte(passphrase), salt, pbkdf2Iterations, credentialKeySize, sha256.New), nil
+	}
+	return loadOrCreateKeyFile(path + ".key")
+}
+
+func loadOrCreateKeyFile(path string) ([]byte, error) {
+	key, err := ioutil.ReadFile(path)
+	if err == nil && len(key) == credentialKeySize {
+		return key, nil
+	}
+
+	key = make([]byte, credentialKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate key file fail: %v", err)
+	}
+	if err := ioutil.WriteFile(path, key, 0600); err != nil {
+		return nil, fmt.Errorf("write key file fail: %v", err)
+	}
+	return key, nil
+}
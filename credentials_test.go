@@ -0,0 +1,56 @@
+package gomcbot
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "creds.enc")
+	want := &Credentials{
+		AccessToken: "access-token",
+		ClientToken: "client-token",
+		UUID:        "11111111-2222-3333-4444-555555555555",
+		Name:        "Steve",
+	}
+
+	if err := Save(path, want, "correct horse battery staple"); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	got, err := Load(path, "correct horse battery staple")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if *got != *want {
+		t.Fatalf("Load() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadWrongPassphraseFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "creds.enc")
+	creds := &Credentials{AccessToken: "access-token", Name: "Steve"}
+
+	if err := Save(path, creds, "correct horse battery staple"); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, err := Load(path, "wrong passphrase"); err == nil {
+		t.Fatal("Load() with wrong passphrase succeeded, want error")
+	}
+}
+
+func TestSaveLoadKeyFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "creds.enc")
+	want := &Credentials{AccessToken: "access-token", Name: "Alex"}
+
+	if err := Save(path, want, ""); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	got, err := Load(path, "")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if *got != *want {
+		t.Fatalf("Load() = %+v, want %+v", got, want)
+	}
+}
@@ -8,12 +8,9 @@ import (
 	"crypto/rsa"
 	"crypto/sha1"
 	"crypto/x509"
-	"encoding/json"
 	"fmt"
 	"github.com/Tnze/gomcbot/CFB8"
 	pk "github.com/Tnze/gomcbot/packet"
-	"io/ioutil"
-	"net/http"
 	"strings"
 )
 
@@ -54,13 +51,17 @@ func unpackEncryptionRequest(p pk.Packet) (*encryptionRequest, error) {
 	return &er, nil
 }
 
-// authDigest computes a special SHA-1 digest required for Minecraft web
+// AuthDigest computes a special SHA-1 digest required for Minecraft web
 // authentication on Premium servers (online-mode=true).
 // Source: http://wiki.vg/Protocol_Encryption#Server
 //
 // Also many, many thanks to SirCmpwn and his wonderful gist (C#):
 // https://gist.github.com/SirCmpwn/404223052379e82f91e6
-func authDigest(serverID string, sharedSecret, publicKey []byte) string {
+//
+// It is exported so that packages implementing the server side of the
+// handshake (see the server package) can compute the same digest to verify
+// clients against Mojang's hasJoined endpoint.
+func AuthDigest(serverID string, sharedSecret, publicKey []byte) string {
 	h := sha1.New()
 	h.Write([]byte(serverID))
 	h.Write(sharedSecret)
@@ -95,55 +96,6 @@ func twosComplement(p []byte) []byte {
 	return p
 }
 
-type profile struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-}
-
-type request struct {
-	AccessToken     string  `json:"accessToken"`
-	SelectedProfile profile `json:"selectedProfile"`
-	ServerID        string  `json:"serverId"`
-}
-
-func loginAuth(AsTk, name, UUID string, shareSecret []byte, er encryptionRequest) error {
-	digest := authDigest(er.ServerID, shareSecret, er.PublicKey)
-
-	client := http.Client{}
-	requestPacket, err := json.Marshal(
-		request{
-			AccessToken: AsTk,
-			SelectedProfile: profile{
-				ID:   UUID,
-				Name: name,
-			},
-			ServerID: digest,
-		},
-	)
-	if err != nil {
-		return fmt.Errorf("create request packet to authenticate faile: %v", err)
-	}
-
-	PostRequest, err := http.NewRequest(http.MethodPost, "https://sessionserver.mojang.com/session/minecraft/join",
-		bytes.NewReader(requestPacket))
-	if err != nil {
-		return fmt.Errorf("make request error: %v", err)
-	}
-	PostRequest.Header.Set("User-Agent", "gomcbot")
-	PostRequest.Header.Set("Connection", "keep-alive")
-	resp, err := client.Do(PostRequest)
-	if err != nil {
-		return fmt.Errorf("post fail: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, _ := ioutil.ReadAll(resp.Body)
-	if resp.Status != "204 No Content" {
-		return fmt.Errorf("auth fail: %s", string(body))
-	}
-	return nil
-}
-
 // AES/CFB8 with random key
 func newSymmetricEncryption() (key []byte, encoStream, decoStream cipher.Stream) {
 	key = make([]byte, 16)
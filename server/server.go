@@ -0,0 +1,441 @@
+// Package server implements the server side of the Minecraft Java Edition
+// login handshake: it generates an RSA keypair, sends an encryptionRequest,
+// decrypts the client's shared secret and verify token, and (in online mode)
+// verifies the player against Mojang's hasJoined endpoint. This mirrors the
+// client flow in the root gomcbot package and lets gomcbot be used to build
+// proxies, honeypots, or fake servers for testing bots.
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tnze/gomcbot"
+	"github.com/Tnze/gomcbot/CFB8"
+	pk "github.com/Tnze/gomcbot/packet"
+)
+
+// defaultHandshakeTimeout bounds how long a connection may take to get from
+// its first byte to a completed login, so a silent or hostile peer can't
+// tie up resources indefinitely. Server.HandshakeTimeout overrides it.
+const defaultHandshakeTimeout = 10 * time.Second
+
+// Profile is the identity returned by Mojang's hasJoined endpoint for a
+// client that has successfully joined.
+type Profile struct {
+	UUID string // canonical hyphenated form: xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx
+	Name string
+}
+
+// Conn is an accepted client connection, past login and ready to exchange
+// play packets over an encrypted net.Conn.
+type Conn struct {
+	net.Conn
+	Profile Profile
+}
+
+// Server accepts Minecraft client connections and drives the server side of
+// the login handshake.
+type Server struct {
+	listener net.Listener
+	key      *rsa.PrivateKey
+	pubKey   []byte // ASN.1 DER, as sent in encryptionRequest
+
+	// OnlineMode controls whether clients must pass the Mojang hasJoined
+	// check before being accepted. Disable it to trust any client without
+	// verifying ownership of the account.
+	OnlineMode bool
+
+	// CompressionThreshold, if zero or positive, is sent to the client in a
+	// SetCompression packet right after encryption is established, the same
+	// negotiation a vanilla server performs before LoginSuccess. A negative
+	// value (the default) disables compression.
+	CompressionThreshold int
+
+	// HandshakeTimeout bounds how long a single connection's login may take
+	// before it's dropped. Zero means defaultHandshakeTimeout.
+	HandshakeTimeout time.Duration
+
+	results  chan handshakeResult
+	inFlight sync.WaitGroup // handleConn goroutines not yet done, gates closing results
+}
+
+// handshakeResult is one handshake's outcome, fed to Accept over a channel
+// so a slow or stalled peer can't block every other queued connection.
+type handshakeResult struct {
+	conn *Conn
+	err  error
+}
+
+// Listen starts a Server listening on addr (e.g. ":25565").
+func Listen(addr string) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen fail: %v", err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		l.Close()
+		return nil, fmt.Errorf("generate RSA key fail: %v", err)
+	}
+	pubKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		l.Close()
+		return nil, fmt.Errorf("marshal public key fail: %v", err)
+	}
+
+	s := &Server{
+		listener:             l,
+		key:                  key,
+		pubKey:               pubKey,
+		OnlineMode:           true,
+		CompressionThreshold: -1,
+		results:              make(chan handshakeResult),
+	}
+	go s.acceptLoop()
+	return s, nil
+}
+
+// acceptLoop hands every accepted connection off to its own goroutine so
+// one stalled peer can't stop the next one from being accepted. Once the
+// listener errors (e.g. after Close), it waits for every handleConn
+// goroutine already in flight to finish sending its result before closing
+// the results channel, so none of them can send on it after it's closed.
+func (s *Server) acceptLoop() {
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			s.inFlight.Wait()
+			s.results <- handshakeResult{err: err}
+			close(s.results)
+			return
+		}
+		s.inFlight.Add(1)
+		go func() {
+			defer s.inFlight.Done()
+			s.handleConn(c)
+		}()
+	}
+}
+
+func (s *Server) handleConn(c net.Conn) {
+	timeout := s.HandshakeTimeout
+	if timeout <= 0 {
+		timeout = defaultHandshakeTimeout
+	}
+	if err := c.SetDeadline(time.Now().Add(timeout)); err != nil {
+		c.Close()
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conn, err := s.handshake(ctx, c)
+	if err != nil {
+		c.Close()
+		return
+	}
+	// Login succeeded; play-state traffic isn't bound by the handshake
+	// timeout.
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return
+	}
+	s.results <- handshakeResult{conn: conn}
+}
+
+// Accept waits for and returns the next client connection that has
+// completed the encrypted login handshake (and hasJoined verification, if
+// OnlineMode is set). Connections that fail the handshake are dropped
+// internally; Accept only ever surfaces a successful Conn or a listener
+// error (from Close or the underlying net.Listener).
+func (s *Server) Accept() (*Conn, error) {
+	r, ok := <-s.results
+	if !ok {
+		return nil, fmt.Errorf("server closed")
+	}
+	return r.conn, r.err
+}
+
+// Close stops the Server from accepting further connections.
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+func (s *Server) handshake(ctx context.Context, c net.Conn) (*Conn, error) {
+	hs, err := pk.RecvPacket(c)
+	if err != nil {
+		return nil, fmt.Errorf("read handshake fail: %v", err)
+	}
+	if err := parseHandshake(hs); err != nil {
+		return nil, err
+	}
+
+	ls, err := pk.RecvPacket(c)
+	if err != nil {
+		return nil, fmt.Errorf("read login start fail: %v", err)
+	}
+	name, err := pk.UnpackString(bytes.NewReader(ls.Data))
+	if err != nil {
+		return nil, fmt.Errorf("unpack login start fail: %v", err)
+	}
+
+	verifyToken := make([]byte, 4)
+	if _, err := rand.Read(verifyToken); err != nil {
+		return nil, fmt.Errorf("generate verify token fail: %v", err)
+	}
+	serverID := randomServerID()
+
+	erp := newEncryptionRequestPacket(serverID, s.pubKey, verifyToken)
+	if _, err := erp.WriteTo(c); err != nil {
+		return nil, fmt.Errorf("send encryption request fail: %v", err)
+	}
+
+	resp, err := pk.RecvPacket(c)
+	if err != nil {
+		return nil, fmt.Errorf("read encryption response fail: %v", err)
+	}
+	sharedSecret, gotVerifyToken, err := unpackEncryptionResponse(resp, s.key)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(verifyToken, gotVerifyToken) {
+		return nil, fmt.Errorf("verify token mismatch")
+	}
+
+	profile := Profile{Name: name}
+	if s.OnlineMode {
+		profile, err = hasJoined(ctx, name, gomcbot.AuthDigest(serverID, sharedSecret, s.pubKey))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if profile.UUID == "" {
+		profile.UUID = offlineUUID(profile.Name)
+	}
+
+	block, err := aes.NewCipher(sharedSecret)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher fail: %v", err)
+	}
+	ec := &cryptoConn{
+		Conn:   c,
+		encode: CFB8.NewCFB8Encrypt(block, sharedSecret),
+		decode: CFB8.NewCFB8Decrypt(block, sharedSecret),
+	}
+
+	// From here on, everything is written through ec so it's encrypted.
+	// Compression (if enabled) must be negotiated before LoginSuccess, the
+	// same order vanilla servers use.
+	if s.CompressionThreshold >= 0 {
+		scp := newSetCompressionPacket(s.CompressionThreshold)
+		if _, err := scp.WriteTo(ec); err != nil {
+			return nil, fmt.Errorf("send set compression fail: %v", err)
+		}
+	}
+
+	lsp := newLoginSuccessPacket(profile.UUID, profile.Name)
+	if _, err := lsp.WriteTo(ec); err != nil {
+		return nil, fmt.Errorf("send login success fail: %v", err)
+	}
+
+	return &Conn{Conn: ec, Profile: profile}, nil
+}
+
+func parseHandshake(p pk.Packet) error {
+	r := bytes.NewReader(p.Data)
+	if _, err := pk.UnpackVarInt(r); err != nil {
+		return fmt.Errorf("unpack protocol version fail: %v", err)
+	}
+	if _, err := pk.UnpackString(r); err != nil {
+		return fmt.Errorf("unpack server address fail: %v", err)
+	}
+	if _, err := pk.UnpackUint16(r); err != nil {
+		return fmt.Errorf("unpack server port fail: %v", err)
+	}
+	nextState, err := pk.ReadNBytes(r, 1)
+	if err != nil {
+		return fmt.Errorf("unpack next state fail: %v", err)
+	}
+	const loginState = 2
+	if nextState[0] != loginState {
+		return fmt.Errorf("unexpected next state: %d", nextState[0])
+	}
+	return nil
+}
+
+func newEncryptionRequestPacket(serverID string, publicKey, verifyToken []byte) *pk.Packet {
+	var data []byte
+	data = append(data, pk.PackString(serverID)...)
+	data = append(data, pk.PackVarInt(int32(len(publicKey)))...)
+	data = append(data, publicKey...)
+	data = append(data, pk.PackVarInt(int32(len(verifyToken)))...)
+	data = append(data, verifyToken...)
+	return &pk.Packet{ID: 0x01, Data: data}
+}
+
+// newLoginSuccessPacket builds the packet that transitions the connection
+// from the login state into play, carrying the resolved account identity.
+func newLoginSuccessPacket(uuid, name string) *pk.Packet {
+	var data []byte
+	data = append(data, pk.PackString(uuid)...)
+	data = append(data, pk.PackString(name)...)
+	return &pk.Packet{ID: 0x02, Data: data}
+}
+
+// newSetCompressionPacket tells the client every packet from now on may be
+// compressed, and below what size packets aren't worth compressing.
+func newSetCompressionPacket(threshold int) *pk.Packet {
+	return &pk.Packet{ID: 0x03, Data: pk.PackVarInt(int32(threshold))}
+}
+
+func unpackEncryptionResponse(p pk.Packet, key *rsa.PrivateKey) (sharedSecret, verifyToken []byte, err error) {
+	r := bytes.NewReader(p.Data)
+	secretLen, err := pk.UnpackVarInt(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unpack shared secret length fail: %v", err)
+	}
+	encSecret, err := pk.ReadNBytes(r, int(secretLen))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read shared secret fail: %v", err)
+	}
+	tokenLen, err := pk.UnpackVarInt(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unpack verify token length fail: %v", err)
+	}
+	encToken, err := pk.ReadNBytes(r, int(tokenLen))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read verify token fail: %v", err)
+	}
+
+	sharedSecret, err = rsa.DecryptPKCS1v15(rand.Reader, key, encSecret)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decrypt shared secret fail: %v", err)
+	}
+	verifyToken, err = rsa.DecryptPKCS1v15(rand.Reader, key, encToken)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decrypt verify token fail: %v", err)
+	}
+	return sharedSecret, verifyToken, nil
+}
+
+// hasJoined asks Mojang's session server whether a client claiming to be
+// name has actually joined, proving it holds a valid access token for the
+// account. See http://wiki.vg/Protocol_Encryption#Server
+//
+// It goes through gomcbot.HTTPClient (for proxying/timeouts) and reports to
+// gomcbot.OnSessionJoin like the client-side join call, passing digest as
+// the serverID argument, and is bound by ctx so a hung Mojang response
+// can't block the handshake forever.
+func hasJoined(ctx context.Context, name, digest string) (profile Profile, err error) {
+	if gomcbot.OnSessionJoin != nil {
+		start := time.Now()
+		defer func() { gomcbot.OnSessionJoin(digest, time.Since(start), err) }()
+	}
+
+	q := url.Values{}
+	q.Set("username", name)
+	q.Set("serverId", digest)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		"https://sessionserver.mojang.com/session/minecraft/hasJoined?"+q.Encode(), nil)
+	if err != nil {
+		return Profile{}, fmt.Errorf("make request error: %v", err)
+	}
+
+	resp, err := gomcbot.HTTPClient.Do(req)
+	if err != nil {
+		return Profile{}, fmt.Errorf("hasJoined request fail: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		err = fmt.Errorf("player %q failed to verify with Mojang", name)
+		return Profile{}, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Profile{}, fmt.Errorf("read hasJoined response fail: %v", err)
+	}
+
+	var p struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if err = json.Unmarshal(body, &p); err != nil {
+		return Profile{}, fmt.Errorf("decode hasJoined response fail: %v", err)
+	}
+	return Profile{UUID: canonicalUUID(p.ID), Name: p.Name}, nil
+}
+
+// canonicalUUID turns Mojang's undashed UUID into the usual hyphenated form.
+func canonicalUUID(id string) string {
+	id = strings.ReplaceAll(id, "-", "")
+	if len(id) != 32 {
+		return id
+	}
+	return fmt.Sprintf("%s-%s-%s-%s-%s", id[0:8], id[8:12], id[12:16], id[16:20], id[20:32])
+}
+
+// offlineUUID derives a version-3 UUID from "OfflinePlayer:<name>", matching
+// vanilla's offline-mode player UUIDs. It's used as profile.UUID's fallback
+// when OnlineMode is off and there's no Mojang-assigned UUID to report.
+func offlineUUID(name string) string {
+	sum := md5.Sum([]byte("OfflinePlayer:" + name))
+	sum[6] = (sum[6] & 0x0f) | 0x30 // version 3
+	sum[8] = (sum[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+const serverIDChars = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// randomServerID generates the serverId used in the encryptionRequest and
+// the later hasJoined check, mirroring vanilla's random 20-char string.
+func randomServerID() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	for i := range b {
+		b[i] = serverIDChars[int(b[i])%len(serverIDChars)]
+	}
+	return string(b)
+}
+
+// cryptoConn wraps a net.Conn with the AES/CFB8 streams agreed during the
+// handshake, so reads and writes past this point are transparently
+// encrypted, matching the client side of newSymmetricEncryption.
+type cryptoConn struct {
+	net.Conn
+	encode, decode cipher.Stream
+}
+
+func (c *cryptoConn) Read(p []byte) (n int, err error) {
+	n, err = c.Conn.Read(p)
+	if n > 0 {
+		c.decode.XORKeyStream(p[:n], p[:n])
+	}
+	return
+}
+
+func (c *cryptoConn) Write(p []byte) (n int, err error) {
+	enc := make([]byte, len(p))
+	c.encode.XORKeyStream(enc, p)
+	return c.Conn.Write(enc)
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	pk "github.com/Tnze/gomcbot/packet"
+)
+
+func TestCanonicalUUID(t *testing.T) {
+	got := canonicalUUID("11111111222233334444555555555555")
+	want := "11111111-2222-3333-4444-555555555555"
+	if got != want {
+		t.Fatalf("canonicalUUID() = %q, want %q", got, want)
+	}
+}
+
+func TestCanonicalUUIDAlreadyDashed(t *testing.T) {
+	id := "11111111-2222-3333-4444-555555555555"
+	if got := canonicalUUID(id); got != id {
+		t.Fatalf("canonicalUUID() = %q, want %q", got, id)
+	}
+}
+
+func TestRandomServerID(t *testing.T) {
+	id := randomServerID()
+	if len(id) != 20 {
+		t.Fatalf("randomServerID() length = %d, want 20", len(id))
+	}
+	for _, c := range id {
+		if !bytes.ContainsRune([]byte(serverIDChars), c) {
+			t.Fatalf("randomServerID() contains unexpected char %q", c)
+		}
+	}
+}
+
+func TestParseHandshake(t *testing.T) {
+	var data []byte
+	data = append(data, pk.PackVarInt(754)...)
+	data = append(data, pk.PackString("localhost")...)
+	data = append(data, pk.PackUint16(25565)...)
+	data = append(data, 2) // next state: login
+
+	if err := parseHandshake(pk.Packet{ID: 0, Data: data}); err != nil {
+		t.Fatalf("parseHandshake() error = %v", err)
+	}
+}
+
+func TestParseHandshakeWrongNextState(t *testing.T) {
+	var data []byte
+	data = append(data, pk.PackVarInt(754)...)
+	data = append(data, pk.PackString("localhost")...)
+	data = append(data, pk.PackUint16(25565)...)
+	data = append(data, 1) // next state: status, not login
+
+	if err := parseHandshake(pk.Packet{ID: 0, Data: data}); err == nil {
+		t.Fatal("parseHandshake() with status next state succeeded, want error")
+	}
+}
+
+func TestEncryptionResponseRoundTrip(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	wantSecret := make([]byte, 16)
+	wantToken := make([]byte, 4)
+	rand.Read(wantSecret)
+	rand.Read(wantToken)
+
+	encSecret, err := rsa.EncryptPKCS1v15(rand.Reader, &key.PublicKey, wantSecret)
+	if err != nil {
+		t.Fatalf("EncryptPKCS1v15(secret) error = %v", err)
+	}
+	encToken, err := rsa.EncryptPKCS1v15(rand.Reader, &key.PublicKey, wantToken)
+	if err != nil {
+		t.Fatalf("EncryptPKCS1v15(token) error = %v", err)
+	}
+
+	var data []byte
+	data = append(data, pk.PackVarInt(int32(len(encSecret)))...)
+	data = append(data, encSecret...)
+	data = append(data, pk.PackVarInt(int32(len(encToken)))...)
+	data = append(data, encToken...)
+
+	gotSecret, gotToken, err := unpackEncryptionResponse(pk.Packet{ID: 1, Data: data}, key)
+	if err != nil {
+		t.Fatalf("unpackEncryptionResponse() error = %v", err)
+	}
+	if !bytes.Equal(gotSecret, wantSecret) {
+		t.Fatalf("unpackEncryptionResponse() secret = %x, want %x", gotSecret, wantSecret)
+	}
+	if !bytes.Equal(gotToken, wantToken) {
+		t.Fatalf("unpackEncryptionResponse() token = %x, want %x", gotToken, wantToken)
+	}
+}
+
+func TestUnpackEncryptionResponseTruncated(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	if _, _, err := unpackEncryptionResponse(pk.Packet{ID: 1, Data: nil}, key); err == nil {
+		t.Fatal("unpackEncryptionResponse() with empty data succeeded, want error")
+	}
+}
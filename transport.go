@@ -0,0 +1,23 @@
+package gomcbot
+
+import (
+	"net/http"
+	"time"
+)
+
+// HTTPClient is used for every outgoing session/auth HTTP call this package
+// makes: join, authenticate, validate, refresh, the Microsoft/XBL/XSTS
+// chain, and injector discovery. Replace it to run behind a proxy (e.g. a
+// SOCKS5 dialer from golang.org/x/net/proxy, or an HTTP CONNECT proxy via
+// http.Transport.Proxy) or to change the timeout; the default below just
+// keeps a hung Mojang request from blocking a bot forever.
+var HTTPClient = &http.Client{
+	Timeout: 15 * time.Second,
+}
+
+// OnSessionJoin, if set, is called after every join-the-server request to
+// the session server, and after every hasJoined check the server package
+// makes (with the server hash digest as serverID), so operators can log or
+// record metrics for Mojang API latency and failures. err is nil on a
+// successful join or check.
+var OnSessionJoin func(serverID string, elapsed time.Duration, err error)